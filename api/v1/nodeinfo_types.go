@@ -0,0 +1,126 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NodeInfoSpec defines the desired state of NodeInfo
+type NodeInfoSpec struct {
+	// Nodename is the name of the Kubernetes node this NodeInfo reports on. It
+	// is looked up against vSphere VM names; without an IdentityRef, a miss
+	// falls back to a match on the node's BIOS UUID.
+	Nodename string `json:"nodename"`
+
+	// IdentityRef optionally points at a Secret, in the same namespace as
+	// this NodeInfo, carrying the vCenter credentials it should be resolved
+	// against, so different NodeInfos can target different vCenters. When
+	// nil, the reconciler falls back to its default (process-wide) vSphere
+	// session.
+	IdentityRef *IdentityRef `json:"identityRef,omitempty"`
+}
+
+// IdentityRef names the Secret backing a per-CR vSphere session.
+type IdentityRef struct {
+	// Name is the name of a Secret with "server", "username", "password" and
+	// (optional) "thumbprint" keys, as consumed by session.ParamsFromSecret.
+	Name string `json:"name"`
+}
+
+// NodeInfoStatus defines the observed state of NodeInfo
+type NodeInfoStatus struct {
+	VMGuestId    string `json:"vmGuestId,omitempty"`
+	VMTotalCPU   int64  `json:"vmTotalCPU,omitempty"`
+	VMResvdCPU   int64  `json:"vmResvdCPU,omitempty"`
+	VMTotalMem   int64  `json:"vmTotalMem,omitempty"`
+	VMResvdMem   int64  `json:"vmResvdMem,omitempty"`
+	VMPowerState string `json:"vmPowerState,omitempty"`
+	VMHwVersion  string `json:"vmHwVersion,omitempty"`
+	VMIpAddress  string `json:"vmIpAddress,omitempty"`
+	PathToVM     string `json:"pathToVM,omitempty"`
+
+	// Networks reports one entry per VM network adapter, so VMs with more
+	// than one NIC, or a mix of standard and distributed switches, are
+	// represented accurately instead of collapsing to a single network.
+	Networks []NetworkStatus `json:"networks,omitempty"`
+
+	// Zone is the value of the tag attached to one of the VM's ancestor
+	// entities (host, cluster or datacenter) whose category matches the
+	// manager's --zone-category flag, mirroring topology.kubernetes.io/zone.
+	Zone string `json:"zone,omitempty"`
+	// Region is the value of the tag attached to one of the VM's ancestor
+	// entities whose category matches the manager's --region-category flag,
+	// mirroring topology.kubernetes.io/region.
+	Region string `json:"region,omitempty"`
+}
+
+// VlanType describes how a distributed portgroup's default port config
+// presents VLANs: a single access VLAN, a trunked range of VLANs, or a
+// private VLAN.
+type VlanType string
+
+const (
+	VlanTypeAccess VlanType = "access"
+	VlanTypeTrunk  VlanType = "trunk"
+	VlanTypePvlan  VlanType = "pvlan"
+)
+
+// VlanRange is an inclusive range of VLAN IDs allowed on a trunk port.
+type VlanRange struct {
+	Start int32 `json:"start"`
+	End   int32 `json:"end"`
+}
+
+// NetworkStatus reports the observed state of a single VM network adapter.
+type NetworkStatus struct {
+	Name          string `json:"name,omitempty"`
+	SwitchType    string `json:"switchType,omitempty"`
+	OverallStatus string `json:"overallStatus,omitempty"`
+
+	// VlanType, VlanId and TrunkRanges are only populated for adapters on a
+	// distributed switch; VlanId is the access or private VLAN ID, TrunkRanges
+	// is only populated when VlanType is trunk.
+	VlanType    VlanType    `json:"vlanType,omitempty"`
+	VlanId      int32       `json:"vlanId,omitempty"`
+	TrunkRanges []VlanRange `json:"trunkRanges,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// NodeInfo is the Schema for the nodeinfoes API
+type NodeInfo struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NodeInfoSpec   `json:"spec,omitempty"`
+	Status NodeInfoStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// NodeInfoList contains a list of NodeInfo
+type NodeInfoList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NodeInfo `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NodeInfo{}, &NodeInfoList{})
+}
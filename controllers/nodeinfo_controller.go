@@ -22,30 +22,63 @@ import (
 	"time"
 
 	"github.com/go-logr/logr"
+	"github.com/vmware/govmomi/find"
 	"github.com/vmware/govmomi/property"
-	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vapi/tags"
 	"github.com/vmware/govmomi/vim25"
 	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/types"
+	corev1 "k8s.io/api/core/v1"
 	k8serr "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	topologyv1 "vkubeviewer/api/v1"
+	"vkubeviewer/pkg/session"
+	"vkubeviewer/pkg/vspherecache"
+	"vkubeviewer/pkg/vsphereevents"
 )
 
+// resyncInterval is the fallback RequeueAfter used alongside the
+// vsphereevents-driven Watches, in case a change is ever missed.
+const resyncInterval = 10 * time.Minute
+
 // NodeInfoReconciler reconciles a NodeInfo object
 type NodeInfoReconciler struct {
 	client.Client
-	VC     *vim25.Client
-	Log    logr.Logger
-	Scheme *runtime.Scheme
+	VC      *vim25.Client
+	VMCache *vspherecache.NodeManager
+	Log     logr.Logger
+	Scheme  *runtime.Scheme
+
+	// Sessions resolves a NodeInfo's Spec.IdentityRef Secret to a vim25.Client
+	// for its own vCenter, shared across every NodeInfo pointing at the same
+	// identity. When a NodeInfo has no IdentityRef, VC is used instead.
+	Sessions *session.Manager
+
+	// Tags is a long-lived tags.Manager, created once in main.go and shared
+	// across reconciles, so looking up zone/region tags does not open a fresh
+	// vAPI REST session on every call.
+	Tags *tags.Manager
+	// ZoneCategory and RegionCategory name the tag categories, attached
+	// somewhere on the VM's Host/Cluster/Datacenter ancestor chain, whose
+	// values are surfaced as Status.Zone and Status.Region.
+	ZoneCategory   string
+	RegionCategory string
+
+	// Events streams vCenter property changes so reconciles happen when a
+	// watched property actually changes, instead of on a fixed poll.
+	Events *vsphereevents.Source
 }
 
 //+kubebuilder:rbac:groups=topology.vkubeviewer.com,resources=nodeinfoes,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=topology.vkubeviewer.com,resources=nodeinfoes/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=topology.vkubeviewer.com,resources=nodeinfoes/finalizers,verbs=update
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -83,98 +116,56 @@ func (r *NodeInfoReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 	// Retrieve Session
 	// ------------
 
-	// Create a view manager
-	m := view.NewManager(r.VC)
-
-	// Create a container view of VirtualMachine objects
-	// vvm - viewer of virtual machine
-	vvm, err := m.CreateContainerView(ctx, r.VC.ServiceContent.RootFolder, []string{"VirtualMachine"}, true)
-
+	// Resolve the node name to a vCenter client and VM reference. NodeInfos
+	// without an IdentityRef resolve in O(1) via the shared default-identity
+	// cache; node names don't always match VM names, so that path falls back
+	// to a lookup by the BIOS UUID surfaced on the Kubernetes Node.
+	vc, vmRef, err := r.resolveVM(ctx, node)
 	if err != nil {
-		msg := fmt.Sprintf("unable to create container view for VirtualMachines: error %s", err)
+		log.Info(err.Error())
+		return ctrl.Result{}, nil
+	}
+
+	// Targeted retrieve of just the properties this controller needs, on the
+	// single resolved VM, instead of retrieving every VM in the inventory.
+	var vm mo.VirtualMachine
+	pc := property.DefaultCollector(vc)
+	if err := pc.RetrieveOne(ctx, vmRef, []string{"summary.config", "summary.runtime", "summary.guest", "network"}, &vm); err != nil {
+		msg := fmt.Sprintf("unable to retrieve VM information: error %s", err)
 		log.Info(msg)
 		return ctrl.Result{}, err
 	}
 
-	defer vvm.Destroy(ctx)
-
-	// Retrieve summary property for all VMs
-	// vms - VirtualMachines
-	var vms []mo.VirtualMachine
-
-	err = vvm.Retrieve(ctx, []string{"VirtualMachine"}, nil, &vms)
-
+	// store VM information
+	node.Status.VMGuestId = string(vm.Summary.Guest.GuestId)
+	node.Status.VMTotalCPU = int64(vm.Summary.Config.NumCpu)
+	node.Status.VMResvdCPU = int64(vm.Summary.Config.CpuReservation)
+	node.Status.VMTotalMem = int64(vm.Summary.Config.MemorySizeMB)
+	node.Status.VMResvdMem = int64(vm.Summary.Config.MemoryReservation)
+	node.Status.VMPowerState = string(vm.Summary.Runtime.PowerState)
+	node.Status.VMHwVersion = string(vm.Summary.Guest.HwVersion)
+	node.Status.VMIpAddress = string(vm.Summary.Guest.IpAddress)
+	node.Status.PathToVM = string(vm.Summary.Config.VmPathName)
+
+	// One entry per NIC, supporting VMs with more than one network adapter
+	// and a mix of standard/distributed switches.
+	networks, err := r.buildNetworkStatuses(ctx, pc, vm)
 	if err != nil {
-		msg := fmt.Sprintf("unable to retrieve VM infomartion: error %s", err)
-		log.Info(msg)
+		log.Info(err.Error())
 		return ctrl.Result{}, err
 	}
-
-	//
-	// Print summary for host in NodeInfo specification info
-	//
-
-	// traverse all the VM
-	for _, vm := range vms {
-		// if the VM's name equals to Nodename
-		if vm.Summary.Config.Name == node.Spec.Nodename {
-
-			// store VM information
-			node.Status.VMGuestId = string(vm.Summary.Guest.GuestId)
-			node.Status.VMTotalCPU = int64(vm.Summary.Config.NumCpu)
-			node.Status.VMResvdCPU = int64(vm.Summary.Config.CpuReservation)
-			node.Status.VMTotalMem = int64(vm.Summary.Config.MemorySizeMB)
-			node.Status.VMResvdMem = int64(vm.Summary.Config.MemoryReservation)
-			node.Status.VMPowerState = string(vm.Summary.Runtime.PowerState)
-			node.Status.VMHwVersion = string(vm.Summary.Guest.HwVersion)
-			node.Status.VMIpAddress = string(vm.Summary.Guest.IpAddress)
-			node.Status.PathToVM = string(vm.Summary.Config.VmPathName)
-
-			// traverse the network, in our operator, we consider only single network
-			for _, ref := range vm.Network {
-				if ref.Type == "Network" {
-					// if it's a normal Network, define the n as DistributedVirtualPortgroup mo.Network
-					var n mo.Network
-					node.Status.SwitchType = "Standard"
-
-					// a property collector to retrieve objects by MOR
-					pc := property.DefaultCollector(r.VC)
-					err = pc.Retrieve(ctx, vm.Network, nil, &n)
-					if err != nil {
-						msg = fmt.Sprintf("unable to retrieve VM Network: error %s", err)
-						log.Info(msg)
-						return ctrl.Result{}, err
-					}
-
-					// store the info in the status
-					node.Status.NetName = string(n.Name)
-					node.Status.NetOverallStatus = string(n.OverallStatus)
-				} else if ref.Type == "DistributedVirtualPortgroup" {
-					// if it's a distributed network, define the n as mo.DistributedVirtualPortgroup
-					var pg mo.DistributedVirtualPortgroup
-					node.Status.SwitchType = "Distributed"
-
-					// a property collector to retrieve objects by MOR
-					pc := property.DefaultCollector(r.VC)
-					err = pc.Retrieve(ctx, vm.Network, nil, &pg)
-					if err != nil {
-						msg = fmt.Sprintf("unable to retrieve VM DVPortGroup: error %s", err)
-						log.Info(msg)
-						return ctrl.Result{}, err
-					}
-
-					// store the info in the status
-					node.Status.NetName = string(pg.Name)
-					node.Status.NetOverallStatus = string(pg.OverallStatus)
-
-					// get vlanID
-					portConfig := pg.Config.DefaultPortConfig.(*types.VMwareDVSPortSetting)
-					vlan := portConfig.Vlan.(*types.VmwareDistributedVirtualSwitchVlanIdSpec)
-					node.Status.VlanId = vlan.VlanId
-
-				}
-			}
-
+	node.Status.Networks = networks
+
+	// Surface the same topology labels topology.kubernetes.io/zone|region
+	// would carry on the node, read from tags attached to the VM's Host,
+	// Cluster or Datacenter ancestors.
+	if r.Tags != nil && vm.Summary.Runtime.Host != nil {
+		zone, region, err := r.lookupTopology(ctx, vc, *vm.Summary.Runtime.Host)
+		if err != nil {
+			log.Info(fmt.Sprintf("unable to look up zone/region tags: %s", err))
+		} else {
+			node.Status.Zone = zone
+			node.Status.Region = region
 		}
 	}
 
@@ -188,12 +179,255 @@ func (r *NodeInfoReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{RequeueAfter: time.Duration(1) * time.Minute}, nil
+	return ctrl.Result{RequeueAfter: resyncInterval}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *NodeInfoReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&topologyv1.NodeInfo{}).
-		Complete(r)
+	bldr := ctrl.NewControllerManagedBy(mgr).
+		For(&topologyv1.NodeInfo{})
+
+	if r.Events != nil {
+		bldr = bldr.Watches(&source.Channel{Source: r.Events.Channel()}, handler.EnqueueRequestsFromMapFunc(r.mapVSphereEvent))
+	}
+
+	return bldr.Complete(r)
+}
+
+// mapVSphereEvent decodes the vsphereevents placeholder object back into the
+// vCenter MOR it reports changed and reconciles only the NodeInfo(s) whose
+// Spec.Nodename matches it, instead of every NodeInfo in the cluster.
+// VirtualMachine updates are mapped through r.VMCache, which already tracks
+// the ref -> name association; HostSystem, Datastore and Network updates
+// aren't (yet) attributable to a specific node, so those conservatively
+// still fan out to every NodeInfo.
+func (r *NodeInfoReconciler) mapVSphereEvent(obj client.Object) []reconcile.Request {
+	ref := types.ManagedObjectReference{Type: obj.GetNamespace(), Value: obj.GetName()}
+
+	if ref.Type != "VirtualMachine" {
+		return r.allNodeInfoRequests()
+	}
+
+	name, ok := r.VMCache.NameForRef(ref)
+	if !ok {
+		return nil
+	}
+
+	var list topologyv1.NodeInfoList
+	if err := r.List(context.Background(), &list); err != nil {
+		r.Log.Error(err, "unable to list NodeInfo in response to vSphere event")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range list.Items {
+		if list.Items[i].Spec.Nodename == name {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&list.Items[i])})
+		}
+	}
+	return requests
+}
+
+// allNodeInfoRequests returns a request for every NodeInfo in the cluster,
+// the conservative fallback for updates that can't be mapped to one node.
+func (r *NodeInfoReconciler) allNodeInfoRequests() []reconcile.Request {
+	var list topologyv1.NodeInfoList
+	if err := r.List(context.Background(), &list); err != nil {
+		r.Log.Error(err, "unable to list NodeInfo in response to vSphere event")
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(list.Items))
+	for i := range list.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&list.Items[i])})
+	}
+	return requests
+}
+
+// resolveVM resolves node to the vCenter client and VM reference it should be
+// reconciled against. A NodeInfo with no IdentityRef uses the default,
+// process-wide session and its O(1) VMCache, falling back to a lookup by the
+// BIOS UUID surfaced on the Kubernetes Node when the name doesn't match any
+// VM. A NodeInfo with an IdentityRef instead logs in (or reuses a cached
+// login) to the vCenter named by its Secret and resolves the VM directly
+// through a Finder; VMCache's inventory walk is only primed for the default
+// identity, so per-identity NodeInfos don't get the O(1) fast path until
+// VMCache learns to key its cache per identity too.
+func (r *NodeInfoReconciler) resolveVM(ctx context.Context, node *topologyv1.NodeInfo) (*vim25.Client, types.ManagedObjectReference, error) {
+	if node.Spec.IdentityRef == nil {
+		vmRef, err := r.VMCache.GetVM(ctx, node.Spec.Nodename)
+		if err == nil {
+			return r.VC, vmRef, nil
+		}
+
+		k8sNode := &corev1.Node{}
+		if getErr := r.Client.Get(ctx, client.ObjectKey{Name: node.Spec.Nodename}, k8sNode); getErr != nil {
+			return nil, types.ManagedObjectReference{}, fmt.Errorf("unable to resolve VM for node %q: %w", node.Spec.Nodename, err)
+		}
+
+		vmRef, err = r.VMCache.GetVMByUUID(ctx, k8sNode.Status.NodeInfo.SystemUUID)
+		if err != nil {
+			return nil, types.ManagedObjectReference{}, fmt.Errorf("unable to resolve VM for node %q by name or BIOS UUID %q: %w",
+				node.Spec.Nodename, k8sNode.Status.NodeInfo.SystemUUID, err)
+		}
+		return r.VC, vmRef, nil
+	}
+
+	secret := &corev1.Secret{}
+	key := client.ObjectKey{Namespace: node.Namespace, Name: node.Spec.IdentityRef.Name}
+	if err := r.Client.Get(ctx, key, secret); err != nil {
+		return nil, types.ManagedObjectReference{}, fmt.Errorf("unable to fetch IdentityRef secret %q: %w", key, err)
+	}
+
+	params, err := session.ParamsFromSecret(secret)
+	if err != nil {
+		return nil, types.ManagedObjectReference{}, fmt.Errorf("invalid IdentityRef secret %q: %w", key, err)
+	}
+	params = params.WithFeature(session.EnableKeepAlive).WithFeature(session.KeepAliveDuration, session.DefaultKeepAlive)
+
+	vc, err := r.Sessions.GetOrCreate(ctx, params)
+	if err != nil {
+		return nil, types.ManagedObjectReference{}, fmt.Errorf("unable to log in to vCenter for IdentityRef %q: %w", key, err)
+	}
+
+	finder := find.NewFinder(vc, true)
+	if dc, dcErr := finder.DefaultDatacenter(ctx); dcErr == nil {
+		finder.SetDatacenter(dc)
+	}
+	vmObj, err := finder.VirtualMachine(ctx, node.Spec.Nodename)
+	if err != nil {
+		return nil, types.ManagedObjectReference{}, fmt.Errorf("unable to resolve VM for node %q via IdentityRef %q: %w", node.Spec.Nodename, key, err)
+	}
+	return vc, vmObj.Reference(), nil
+}
+
+// lookupTopology walks the Host -> Cluster -> Datacenter ancestor chain
+// starting at parent and returns the first values found for r.ZoneCategory
+// and r.RegionCategory among the tags attached to those entities.
+func (r *NodeInfoReconciler) lookupTopology(ctx context.Context, vc *vim25.Client, parent types.ManagedObjectReference) (zone, region string, err error) {
+	pc := property.DefaultCollector(vc)
+
+	ancestors, err := mo.Ancestors(ctx, vc, pc.Reference(), parent)
+	if err != nil {
+		return "", "", err
+	}
+
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		attached, err := r.Tags.GetAttachedTags(ctx, ancestors[i].Self)
+		if err != nil {
+			return "", "", err
+		}
+
+		for _, tag := range attached {
+			category, err := r.Tags.GetCategory(ctx, tag.CategoryID)
+			if err != nil {
+				return "", "", err
+			}
+
+			switch category.Name {
+			case r.ZoneCategory:
+				if zone == "" {
+					zone = tag.Name
+				}
+			case r.RegionCategory:
+				if region == "" {
+					region = tag.Name
+				}
+			}
+		}
+
+		if zone != "" && region != "" {
+			break
+		}
+	}
+
+	return zone, region, nil
+}
+
+// buildNetworkStatuses reports one NetworkStatus per entry in vm.Network. It
+// does one bulk retrieve for the properties common to every network
+// (standard or distributed), plus, when there are any distributed
+// portgroups, a second bulk retrieve scoped to just their VLAN
+// configuration — two round trips total regardless of how many NICs the VM
+// has, instead of one retrieve per NIC into a single shared destination.
+func (r *NodeInfoReconciler) buildNetworkStatuses(ctx context.Context, pc *property.Collector, vm mo.VirtualMachine) ([]topologyv1.NetworkStatus, error) {
+	if len(vm.Network) == 0 {
+		return nil, nil
+	}
+
+	var entities []mo.ManagedEntity
+	if err := pc.Retrieve(ctx, vm.Network, []string{"name", "overallStatus"}, &entities); err != nil {
+		return nil, fmt.Errorf("unable to retrieve VM networks: %w", err)
+	}
+	entityByRef := make(map[types.ManagedObjectReference]mo.ManagedEntity, len(entities))
+	for _, entity := range entities {
+		entityByRef[entity.Self] = entity
+	}
+
+	var dvpgRefs []types.ManagedObjectReference
+	for _, ref := range vm.Network {
+		if ref.Type == "DistributedVirtualPortgroup" {
+			dvpgRefs = append(dvpgRefs, ref)
+		}
+	}
+
+	dvpgByRef := make(map[types.ManagedObjectReference]mo.DistributedVirtualPortgroup, len(dvpgRefs))
+	if len(dvpgRefs) > 0 {
+		var dvpgs []mo.DistributedVirtualPortgroup
+		if err := pc.Retrieve(ctx, dvpgRefs, []string{"config.defaultPortConfig"}, &dvpgs); err != nil {
+			return nil, fmt.Errorf("unable to retrieve VM DVPortGroups: %w", err)
+		}
+		for _, pg := range dvpgs {
+			dvpgByRef[pg.Self] = pg
+		}
+	}
+
+	networks := make([]topologyv1.NetworkStatus, 0, len(vm.Network))
+	for _, ref := range vm.Network {
+		entity := entityByRef[ref]
+		status := topologyv1.NetworkStatus{
+			Name:          entity.Name,
+			OverallStatus: string(entity.OverallStatus),
+		}
+
+		if ref.Type != "DistributedVirtualPortgroup" {
+			status.SwitchType = "Standard"
+			networks = append(networks, status)
+			continue
+		}
+		status.SwitchType = "Distributed"
+
+		pg, ok := dvpgByRef[ref]
+		if !ok || pg.Config.DefaultPortConfig == nil {
+			networks = append(networks, status)
+			continue
+		}
+
+		portConfig, ok := pg.Config.DefaultPortConfig.(*types.VMwareDVSPortSetting)
+		if !ok || portConfig.Vlan == nil {
+			networks = append(networks, status)
+			continue
+		}
+
+		switch vlan := portConfig.Vlan.(type) {
+		case *types.VmwareDistributedVirtualSwitchVlanIdSpec:
+			status.VlanType = topologyv1.VlanTypeAccess
+			status.VlanId = vlan.VlanId
+		case *types.VmwareDistributedVirtualSwitchTrunkVlanSpec:
+			status.VlanType = topologyv1.VlanTypeTrunk
+			for _, vlanRange := range vlan.VlanId {
+				status.TrunkRanges = append(status.TrunkRanges, topologyv1.VlanRange{
+					Start: vlanRange.Start,
+					End:   vlanRange.End,
+				})
+			}
+		case *types.VmwareDistributedVirtualSwitchPvlanSpec:
+			status.VlanType = topologyv1.VlanTypePvlan
+			status.VlanId = vlan.PvlanId
+		}
+
+		networks = append(networks, status)
+	}
+
+	return networks, nil
 }
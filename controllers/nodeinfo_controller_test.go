@@ -0,0 +1,88 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	topologyv1 "vkubeviewer/api/v1"
+	"vkubeviewer/pkg/vspherecache"
+)
+
+var _ = Describe("NodeInfo controller", func() {
+	const (
+		namespace = "default"
+		timeout   = "10s"
+		interval  = "250ms"
+	)
+
+	It("populates Status from the simulated VM, including distributed switch and VLAN", func() {
+		vmName, err := vcsim.VMs[0].ObjectName(ctx)
+		Expect(err).NotTo(HaveOccurred())
+
+		reconciler := &NodeInfoReconciler{
+			Client:  k8sClient,
+			VC:      vcsim.Client,
+			VMCache: vspherecache.NewNodeManager(vcsim.Client),
+			Log:     logf.Log.WithName("NodeInfo"),
+			Scheme:  k8sClient.Scheme(),
+		}
+
+		name := fmt.Sprintf("nodeinfo-%s", vmName)
+		nodeInfo := &topologyv1.NodeInfo{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+			},
+			Spec: topologyv1.NodeInfoSpec{
+				Nodename: vmName,
+			},
+		}
+		Expect(k8sClient.Create(ctx, nodeInfo)).To(Succeed())
+
+		key := types.NamespacedName{Name: name, Namespace: namespace}
+
+		_, err = reconciler.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+
+		created := &topologyv1.NodeInfo{}
+		Eventually(func() error {
+			return k8sClient.Get(ctx, key, created)
+		}, timeout, interval).Should(Succeed())
+
+		Expect(created.Status.Networks).To(HaveLen(1))
+		network := created.Status.Networks[0]
+		Expect(network.SwitchType).To(Equal("Distributed"))
+		Expect(network.VlanType).To(Equal(topologyv1.VlanTypeAccess))
+		Expect(network.VlanId).To(Equal(int32(100)))
+		Expect(network.OverallStatus).NotTo(BeEmpty())
+
+		Expect(k8sClient.Delete(ctx, nodeInfo)).To(Succeed())
+		Eventually(func() bool {
+			return apierrors.IsNotFound(k8sClient.Get(ctx, key, &topologyv1.NodeInfo{}))
+		}, timeout, interval).Should(BeTrue())
+	})
+})
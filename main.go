@@ -21,14 +21,13 @@ import (
 	"flag"
 	"net/url"
 	"os"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
-	"github.com/vmware/govmomi"
 	"github.com/vmware/govmomi/find"
-	"github.com/vmware/govmomi/session/cache"
-	"github.com/vmware/govmomi/vim25"
-	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vapi/rest"
+	"github.com/vmware/govmomi/vapi/tags"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
 	"k8s.io/apimachinery/pkg/runtime"
@@ -40,6 +39,9 @@ import (
 
 	topologyv1 "vkubeviewer/api/v1"
 	"vkubeviewer/controllers"
+	"vkubeviewer/pkg/session"
+	"vkubeviewer/pkg/vspherecache"
+	"vkubeviewer/pkg/vsphereevents"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -55,84 +57,24 @@ func init() {
 	//+kubebuilder:scaffold:scheme
 }
 
-// - vSphere session login function
-//
-
-func vlogin(ctx context.Context, vc, user, pwd string) (*vim25.Client, *govmomi.Client, error) {
-
-	//
-	// This section allows for insecure govmomi logins
-	//
-
-	var insecure bool
-	flag.BoolVar(&insecure, "insecure", true, "ignore any vCenter TLS cert validation error")
-
-	//
-	// Create a vSphere/vCenter client
-	//
-	// The govmomi client requires a URL object, u.
-	// You cannot use a string representation of the vCenter URL.
-	// soap.ParseURL provides the correct object format.
-	//
-
-	u, err := soap.ParseURL(vc)
-
-	if u == nil {
-		setupLog.Error(err, "Unable to parse URL. Are required environment variables set?", "controller", "NodeInfo")
-		os.Exit(1)
-	}
-
-	if err != nil {
-		setupLog.Error(err, "URL parsing not successful", "controller", "NodeInfo")
-		os.Exit(1)
-	}
-
-	u.User = url.UserPassword(user, pwd)
-
-	//
-	// Session cache example taken from https://github.com/vmware/govmomi/blob/master/examples/examples.go
-	//
-	// Share govc's session cache
-	//
-	s := &cache.Session{
-		URL:      u,
-		Insecure: true,
-	}
-
-	//
-	// Create new client
-	//
-	c1 := new(vim25.Client)
-
-	//
-	// Login using client c and cache s
-	//
-	err = s.Login(ctx, c1, nil)
-
-	if err != nil {
-		setupLog.Error(err, "FCDInfo: vim25 login not successful", "controller", "NodeInfo")
-		os.Exit(1)
-	}
-
-	c2, err := govmomi.NewClient(ctx, u, insecure)
-
-	if err != nil {
-		setupLog.Error(err, "FCDInfo: gomvomi login not successful", "controller", "NodeInfo")
-		os.Exit(1)
-	}
-
-	return c1, c2, nil
-}
-
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var thumbprint string
+	var keepAlive time.Duration
+	var zoneCategory string
+	var regionCategory string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&thumbprint, "vcenter-thumbprint", "", "SHA1 or SHA256 thumbprint of the vCenter TLS certificate. "+
+		"When unset, the default vCenter session connects insecurely.")
+	flag.DurationVar(&keepAlive, "vcenter-keep-alive", session.DefaultKeepAlive, "Interval between SOAP keep-alive pings for the default vCenter session.")
+	flag.StringVar(&zoneCategory, "zone-category", "k8s-zone", "Name of the vSphere tag category surfaced as NodeInfo Status.Zone.")
+	flag.StringVar(&regionCategory, "region-category", "k8s-region", "Name of the vSphere tag category surfaced as NodeInfo Status.Region.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -170,7 +112,22 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	c1, c2, err := vlogin(ctx, vc, user, pwd)
+	sessions := session.NewManager()
+
+	params := session.NewParams().
+		WithServer(vc).
+		WithUserInfo(user, pwd).
+		WithThumbprint(thumbprint).
+		WithFeature(session.EnableKeepAlive).
+		WithFeature(session.KeepAliveDuration, keepAlive)
+
+	c1, err := sessions.GetOrCreate(ctx, params)
+	if err != nil {
+		setupLog.Error(err, "unable to get login session to vSphere")
+		os.Exit(1)
+	}
+
+	c2, err := sessions.GetOrCreateGovmomiClient(ctx, params)
 	if err != nil {
 		setupLog.Error(err, "unable to get login session to vSphere")
 		os.Exit(1)
@@ -190,6 +147,37 @@ func main() {
 		finder.SetDatacenter(dc)
 	}
 
+	//
+	// Shared VM inventory cache used by NodeInfoReconciler to resolve a node
+	// name to a VM reference in O(1) instead of re-listing every VM on each
+	// reconcile.
+	//
+
+	nodeCache := vspherecache.NewNodeManager(c1)
+	nodeCache.Start(ctx, ctrl.Log.WithName("vspherecache"))
+
+	//
+	// One long-lived tags.Manager per process, backed by its own vAPI REST
+	// session, so zone/region tag lookups don't open a fresh REST session on
+	// every reconcile.
+	//
+
+	restClient := rest.NewClient(c2.Client)
+	session.KeepAliveREST(ctx, restClient, url.UserPassword(user, pwd), keepAlive)
+	if err := restClient.Login(ctx, url.UserPassword(user, pwd)); err != nil {
+		setupLog.Error(err, "unable to log in to vCenter REST API")
+		os.Exit(1)
+	}
+	tagsManager := tags.NewManager(restClient)
+
+	//
+	// Single PropertyCollector-driven event stream, given to
+	// NodeInfoReconciler in place of fixed-interval polling.
+	//
+
+	vsphereEvents := vsphereevents.NewSource(c1, ctrl.Log.WithName("vsphereevents"), 30*time.Minute)
+	vsphereEvents.Start(ctx)
+
 	//Modified Reconcile call
 	//----
 	if err = (&controllers.FCDInfoReconciler{
@@ -205,10 +193,16 @@ func main() {
 	}
 
 	if err = (&controllers.NodeInfoReconciler{
-		Client: mgr.GetClient(),
-		VC:     c1,
-		Log:    ctrl.Log.WithName("controllers").WithName("NodeInfo"),
-		Scheme: mgr.GetScheme(),
+		Client:         mgr.GetClient(),
+		VC:             c1,
+		VMCache:        nodeCache,
+		Sessions:       sessions,
+		Tags:           tagsManager,
+		ZoneCategory:   zoneCategory,
+		RegionCategory: regionCategory,
+		Events:         vsphereEvents,
+		Log:            ctrl.Log.WithName("controllers").WithName("NodeInfo"),
+		Scheme:         mgr.GetScheme(),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "NodeInfo")
 		os.Exit(1)
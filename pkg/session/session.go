@@ -0,0 +1,309 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package session provides a cache of authenticated vSphere sessions shared
+// across the vkubeviewer reconcilers, so that NodeInfo, HostInfo,
+// DatastoreInfo and FCDInfo do not each open (and separately keep alive)
+// their own connection to the same vCenter.
+package session
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/vapi/rest"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/soap"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Secret key names read by ParamsFromSecret off a CR's IdentityRef Secret.
+const (
+	SecretKeyServer     = "server"
+	SecretKeyUsername   = "username"
+	SecretKeyPassword   = "password"
+	SecretKeyThumbprint = "thumbprint"
+)
+
+// Feature is an opt-in behaviour toggled on a Params via WithFeature.
+type Feature string
+
+const (
+	// EnableKeepAlive starts a background goroutine that issues a SOAP
+	// keep-alive on the session's configured interval so that vCenter does
+	// not drop the connection during idle periods between reconciles.
+	EnableKeepAlive Feature = "EnableKeepAlive"
+
+	// KeepAliveDuration sets the interval between keep-alive pings. Pass the
+	// time.Duration as the value argument to WithFeature. Only meaningful
+	// alongside EnableKeepAlive; defaults to DefaultKeepAlive otherwise.
+	KeepAliveDuration Feature = "KeepAliveDuration"
+)
+
+// DefaultKeepAlive is the keep-alive interval used when a session enables
+// EnableKeepAlive without an explicit KeepAliveDuration.
+const DefaultKeepAlive = 5 * time.Minute
+
+// Params collects the inputs required to establish, or look up, a cached
+// vSphere session. Build one with NewParams and the fluent With* methods, for
+// example:
+//
+//	params := session.NewParams().
+//		WithServer(server).
+//		WithDatacenter(datacenter).
+//		WithUserInfo(username, password).
+//		WithThumbprint(thumbprint).
+//		WithFeature(session.EnableKeepAlive).
+//		WithFeature(session.KeepAliveDuration, time.Minute)
+type Params struct {
+	server     string
+	datacenter string
+	userinfo   *url.Userinfo
+	thumbprint string
+
+	keepAlive         bool
+	keepAliveInterval time.Duration
+}
+
+// NewParams returns an empty Params ready for the fluent With* calls.
+func NewParams() *Params {
+	return &Params{keepAliveInterval: DefaultKeepAlive}
+}
+
+// WithServer sets the vCenter hostname or IP address.
+func (p *Params) WithServer(server string) *Params {
+	p.server = server
+	return p
+}
+
+// WithDatacenter sets the datacenter the session is scoped to. It only
+// participates in the session cache key, callers still need a Finder to
+// resolve it.
+func (p *Params) WithDatacenter(datacenter string) *Params {
+	p.datacenter = datacenter
+	return p
+}
+
+// WithUserInfo sets the credentials used to log in to vCenter.
+func (p *Params) WithUserInfo(username, password string) *Params {
+	p.userinfo = url.UserPassword(username, password)
+	return p
+}
+
+// WithThumbprint pins the expected SHA1 or SHA256 thumbprint of the vCenter
+// TLS certificate. When empty, the session falls back to an insecure
+// connection, matching the historical vkubeviewer behaviour.
+func (p *Params) WithThumbprint(thumbprint string) *Params {
+	p.thumbprint = thumbprint
+	return p
+}
+
+// WithFeature enables an optional session behaviour. See EnableKeepAlive and
+// KeepAliveDuration. Each feature is a separate WithFeature call: it switches
+// on feature alone and reads the rest of value against that one feature, so
+// EnableKeepAlive and KeepAliveDuration cannot be combined into a single
+// call.
+func (p *Params) WithFeature(feature Feature, value ...interface{}) *Params {
+	switch feature {
+	case EnableKeepAlive:
+		p.keepAlive = true
+	case KeepAliveDuration:
+		if len(value) == 1 {
+			if d, ok := value[0].(time.Duration); ok {
+				p.keepAliveInterval = d
+			}
+		}
+	}
+	return p
+}
+
+// ParamsFromSecret builds Params from a Secret populated with "server",
+// "username", "password" and (optional) "thumbprint" keys, as referenced by a
+// CR's IdentityRef. This lets each CR target its own vCenter instead of
+// sharing the process-wide credentials main.go reads from GOVMOMI_* env vars.
+// It does not set WithDatacenter or WithFeature; callers add those as needed.
+func ParamsFromSecret(secret *corev1.Secret) (*Params, error) {
+	server, ok := secret.Data[SecretKeyServer]
+	if !ok || len(server) == 0 {
+		return nil, fmt.Errorf("secret %s/%s: missing required key %q", secret.Namespace, secret.Name, SecretKeyServer)
+	}
+	username, ok := secret.Data[SecretKeyUsername]
+	if !ok {
+		return nil, fmt.Errorf("secret %s/%s: missing required key %q", secret.Namespace, secret.Name, SecretKeyUsername)
+	}
+
+	return NewParams().
+		WithServer(string(server)).
+		WithUserInfo(string(username), string(secret.Data[SecretKeyPassword])).
+		WithThumbprint(string(secret.Data[SecretKeyThumbprint])), nil
+}
+
+// key returns the cache key a session is stored under: server, datacenter,
+// username and thumbprint together identify one logical vCenter identity.
+func (p *Params) key() string {
+	username := ""
+	if p.userinfo != nil {
+		username = p.userinfo.Username()
+	}
+	return fmt.Sprintf("%s/%s/%s/%s", p.server, p.datacenter, username, p.thumbprint)
+}
+
+// cachedSession wraps a govmomi client together with the cancel func for its
+// keep-alive goroutine, if any.
+type cachedSession struct {
+	*govmomi.Client
+	cancel context.CancelFunc
+}
+
+// Manager caches authenticated vim25 clients keyed by (server, datacenter,
+// username, thumbprint), so CRs targeting the same vCenter identity share one
+// login and one keep-alive loop instead of each reconcile paying for its own.
+type Manager struct {
+	mu       sync.RWMutex
+	sessions map[string]*cachedSession
+}
+
+// NewManager returns an empty session Manager.
+func NewManager() *Manager {
+	return &Manager{sessions: make(map[string]*cachedSession)}
+}
+
+// GetOrCreate returns a cached, authenticated vim25.Client for params,
+// establishing a new session (and, if requested, its keep-alive loop) when
+// none is cached yet or the cached one is no longer authenticated. The
+// common case - a cache hit against an already-active session - only ever
+// takes a read lock. Establishing or replacing a session holds the Manager's
+// write lock for the whole login, so while a login is in progress every
+// other GetOrCreate call, for any vCenter identity, waits for it to finish.
+func (m *Manager) GetOrCreate(ctx context.Context, params *Params) (*vim25.Client, error) {
+	key := params.key()
+
+	m.mu.RLock()
+	cached, ok := m.sessions[key]
+	m.mu.RUnlock()
+
+	if ok {
+		if active, err := cached.SessionManager.SessionIsActive(ctx); err == nil && active {
+			return cached.Client.Client, nil
+		}
+	}
+
+	// Either there was no cached session, or the one we saw needs replacing.
+	// Take the write lock and re-check: another goroutine may have already
+	// replaced or removed it while we were probing SessionIsActive, or while
+	// we were waiting for the lock.
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if cached, ok := m.sessions[key]; ok {
+		active, err := cached.SessionManager.SessionIsActive(ctx)
+		if err == nil && active {
+			return cached.Client.Client, nil
+		}
+		// Session is stale; tear down its keep-alive loop and fall through
+		// to re-establish it below.
+		cached.cancel()
+		delete(m.sessions, key)
+	}
+
+	cached, err := newSession(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+
+	m.sessions[key] = cached
+	return cached.Client.Client, nil
+}
+
+// GetOrCreateGovmomiClient is GetOrCreate for callers that additionally need
+// the higher-level *govmomi.Client, for example to build a find.Finder.
+func (m *Manager) GetOrCreateGovmomiClient(ctx context.Context, params *Params) (*govmomi.Client, error) {
+	if _, err := m.GetOrCreate(ctx, params); err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.sessions[params.key()].Client, nil
+}
+
+// newSession logs in to vCenter, validating the server certificate against
+// params.thumbprint when one is supplied, and, when params.keepAlive is set,
+// wires up a keep-alive loop that transparently re-logs in when vCenter
+// reports the session is no longer authenticated.
+func newSession(ctx context.Context, params *Params) (*cachedSession, error) {
+	u, err := soap.ParseURL(params.server)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse vCenter server %q: %w", params.server, err)
+	}
+	u.User = params.userinfo
+
+	soapClient := soap.NewClient(u, params.thumbprint == "")
+	if params.thumbprint != "" {
+		soapClient.SetThumbprint(u.Host, params.thumbprint)
+	}
+
+	vimClient, err := vim25.NewClient(ctx, soapClient)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create vim25 client for %q: %w", params.server, err)
+	}
+
+	c := &govmomi.Client{
+		Client:         vimClient,
+		SessionManager: session.NewManager(vimClient),
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	if params.keepAlive {
+		vimClient.RoundTripper = session.KeepAliveHandler(vimClient.RoundTripper, params.keepAliveInterval, func(rt soap.RoundTripper) error {
+			active, err := c.SessionManager.SessionIsActive(ctx)
+			if err != nil || !active {
+				return c.SessionManager.Login(ctx, params.userinfo)
+			}
+			return nil
+		})
+	}
+
+	if err := c.SessionManager.Login(ctx, params.userinfo); err != nil {
+		cancel()
+		return nil, fmt.Errorf("unable to log in to vCenter %q: %w", params.server, err)
+	}
+
+	return &cachedSession{Client: c, cancel: cancel}, nil
+}
+
+// KeepAliveREST wraps a vAPI REST client's RoundTripper with a keep-alive
+// loop analogous to the one GetOrCreate already runs for vim25 sessions: on
+// interval, it checks whether the REST session is still valid and re-logs in
+// with userinfo when it is not. Call it once, right after constructing the
+// client and before its first Login, so every call through it benefits.
+// Without this, a vAPI session cookie silently expires and every call
+// through client starts failing for the rest of the process's life.
+func KeepAliveREST(ctx context.Context, client *rest.Client, userinfo *url.Userinfo, interval time.Duration) {
+	client.RoundTripper = session.KeepAliveHandler(client.RoundTripper, interval, func(rt soap.RoundTripper) error {
+		s, err := client.Session(ctx)
+		if err != nil || s == nil {
+			return client.Login(ctx, userinfo)
+		}
+		return nil
+	})
+}
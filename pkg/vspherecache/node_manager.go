@@ -0,0 +1,313 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package vspherecache maintains an in-memory view of the VirtualMachine
+// inventory so reconcilers can resolve a node name to its vSphere managed
+// object reference in O(1), instead of re-listing and linearly scanning every
+// VM in the inventory on each reconcile.
+package vspherecache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// ErrVMNotFound is returned by GetVM and GetVMByUUID when no VM is cached
+// under the requested name or BIOS UUID.
+var ErrVMNotFound = errors.New("vspherecache: VM not found")
+
+// vmEntry is the cached information the reconcilers need to go straight to a
+// targeted property collection, without walking the whole inventory again.
+type vmEntry struct {
+	vmRef types.ManagedObjectReference
+	dcRef types.ManagedObjectReference
+	name  string
+	uuid  string
+}
+
+// NodeManager is a shared, read-mostly cache mapping a VM's name (and BIOS
+// UUID) to its managed object reference. It is safe for concurrent use by
+// every reconciler that needs to resolve a Kubernetes node to a VM.
+type NodeManager struct {
+	client *vim25.Client
+
+	mu     sync.RWMutex
+	byRef  map[types.ManagedObjectReference]vmEntry
+	byName map[string]vmEntry
+	byUUID map[string]vmEntry
+
+	primed  bool
+	primeMu sync.Mutex
+}
+
+// NewNodeManager returns a NodeManager backed by client. The cache is primed
+// lazily by the first GetVM or GetVMByUUID call; Start keeps it in sync with
+// incremental updates but does not mark it primed.
+func NewNodeManager(client *vim25.Client) *NodeManager {
+	return &NodeManager{
+		client: client,
+		byRef:  make(map[types.ManagedObjectReference]vmEntry),
+		byName: make(map[string]vmEntry),
+		byUUID: make(map[string]vmEntry),
+	}
+}
+
+// GetVM resolves nodeName to a VM reference in O(1) via the cache, priming it
+// on first call.
+func (m *NodeManager) GetVM(ctx context.Context, nodeName string) (types.ManagedObjectReference, error) {
+	if err := m.ensurePrimed(ctx); err != nil {
+		return types.ManagedObjectReference{}, err
+	}
+
+	m.mu.RLock()
+	entry, ok := m.byName[nodeName]
+	m.mu.RUnlock()
+	if !ok {
+		return types.ManagedObjectReference{}, ErrVMNotFound
+	}
+	return entry.vmRef, nil
+}
+
+// GetVMByUUID resolves a VM by its BIOS UUID. Reconcilers fall back to this
+// when the node name does not match any VM name, which is common when
+// Kubernetes node names are not kept in sync with VM names; the BIOS UUID is
+// available from the corresponding corev1.Node's Status.NodeInfo.SystemUUID.
+func (m *NodeManager) GetVMByUUID(ctx context.Context, uuid string) (types.ManagedObjectReference, error) {
+	if err := m.ensurePrimed(ctx); err != nil {
+		return types.ManagedObjectReference{}, err
+	}
+
+	m.mu.RLock()
+	entry, ok := m.byUUID[uuid]
+	m.mu.RUnlock()
+	if !ok {
+		return types.ManagedObjectReference{}, ErrVMNotFound
+	}
+	return entry.vmRef, nil
+}
+
+// NameForRef returns the VM name cached for ref, without priming or
+// rebuilding the cache. Callers use this to map a vSphere property-collector
+// update back to the specific node it affects, instead of treating every
+// update as relevant to every node.
+func (m *NodeManager) NameForRef(ref types.ManagedObjectReference) (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entry, ok := m.byRef[ref]
+	return entry.name, ok
+}
+
+// ensurePrimed performs the one-time, full container view walk the first time
+// the cache is used. Subsequent calls are no-ops until Invalidate is called.
+func (m *NodeManager) ensurePrimed(ctx context.Context) error {
+	m.primeMu.Lock()
+	defer m.primeMu.Unlock()
+
+	if m.primed {
+		return nil
+	}
+	if err := m.rebuild(ctx); err != nil {
+		return err
+	}
+	m.primed = true
+	return nil
+}
+
+// Invalidate forces the next GetVM/GetVMByUUID call to rebuild the cache from
+// a fresh container view walk.
+func (m *NodeManager) Invalidate() {
+	m.primeMu.Lock()
+	m.primed = false
+	m.primeMu.Unlock()
+}
+
+// rebuild walks the VirtualMachine container view once and replaces the
+// cached name/UUID indexes.
+func (m *NodeManager) rebuild(ctx context.Context) error {
+	mgr := view.NewManager(m.client)
+
+	vvm, err := mgr.CreateContainerView(ctx, m.client.ServiceContent.RootFolder, []string{"VirtualMachine"}, true)
+	if err != nil {
+		return err
+	}
+	defer vvm.Destroy(ctx)
+
+	var vms []mo.VirtualMachine
+	if err := vvm.Retrieve(ctx, []string{"VirtualMachine"}, []string{"summary.config", "parent"}, &vms); err != nil {
+		return err
+	}
+
+	byRef := make(map[types.ManagedObjectReference]vmEntry, len(vms))
+	byName := make(map[string]vmEntry, len(vms))
+	byUUID := make(map[string]vmEntry, len(vms))
+
+	for _, vm := range vms {
+		entry := vmEntry{vmRef: vm.Self, name: vm.Summary.Config.Name, uuid: vm.Summary.Config.Uuid}
+		if vm.Parent != nil {
+			entry.dcRef = *vm.Parent
+		}
+		byRef[entry.vmRef] = entry
+		byName[entry.name] = entry
+		if entry.uuid != "" {
+			byUUID[entry.uuid] = entry
+		}
+	}
+
+	m.mu.Lock()
+	m.byRef = byRef
+	m.byName = byName
+	m.byUUID = byUUID
+	m.mu.Unlock()
+
+	return nil
+}
+
+// Start runs Watch in a background goroutine until ctx is cancelled,
+// re-subscribing with a capped exponential backoff whenever the underlying
+// filter ends early, for example because the session it was opened on was
+// re-logged in by pkg/session. Without this, a dropped filter would leave
+// GetVM/GetVMByUUID serving permanently stale results for the rest of the
+// process's life.
+func (m *NodeManager) Start(ctx context.Context, log logr.Logger) {
+	go m.run(ctx, log)
+}
+
+func (m *NodeManager) run(ctx context.Context, log logr.Logger) {
+	backoff := time.Second
+	const maxBackoff = time.Minute
+
+	for ctx.Err() == nil {
+		if err := m.Watch(ctx); err != nil && ctx.Err() == nil {
+			log.Error(err, "vSphere VM inventory watch ended, re-subscribing", "backoff", backoff)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return
+			}
+			if backoff < maxBackoff {
+				backoff *= 2
+			}
+			continue
+		}
+		backoff = time.Second
+	}
+}
+
+// Watch opens a single PropertyCollector filter over the VirtualMachine
+// container view and keeps the cache in sync with incremental updates until
+// ctx is cancelled. It blocks, so callers should run it in its own goroutine,
+// or call Start instead to get automatic re-subscription.
+func (m *NodeManager) Watch(ctx context.Context) error {
+	mgr := view.NewManager(m.client)
+
+	vvm, err := mgr.CreateContainerView(ctx, m.client.ServiceContent.RootFolder, []string{"VirtualMachine"}, true)
+	if err != nil {
+		return err
+	}
+	defer vvm.Destroy(ctx)
+
+	pc := property.DefaultCollector(m.client)
+
+	return property.WaitForUpdatesEx(ctx, pc, []types.PropertyFilterSpec{{
+		ObjectSet: []types.ObjectSpec{{
+			Obj:  vvm.Reference(),
+			Skip: types.NewBool(true),
+			SelectSet: []types.BaseSelectionSpec{&types.TraversalSpec{
+				Path: "view",
+				Skip: types.NewBool(false),
+				Type: "ContainerView",
+			}},
+		}},
+		PropSet: []types.PropertySpec{{
+			Type:    "VirtualMachine",
+			PathSet: []string{"summary.config", "parent"},
+		}},
+	}}, func(updates []types.ObjectUpdate) bool {
+		m.applyUpdates(updates)
+		return false
+	})
+}
+
+// applyUpdates folds a batch of incremental VM updates into the cache,
+// removing entries for VMs that have left the container view.
+func (m *NodeManager) applyUpdates(updates []types.ObjectUpdate) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, update := range updates {
+		old, hadOld := m.byRef[update.Obj]
+
+		if update.Kind == types.ObjectUpdateKindLeave {
+			if hadOld {
+				delete(m.byRef, update.Obj)
+				delete(m.byName, old.name)
+				delete(m.byUUID, old.uuid)
+			}
+			continue
+		}
+
+		// An incremental "modify" update's ChangeSet only carries the
+		// properties that actually changed in this batch - for example a VM
+		// moving folders may report a new parent with no summary.config at
+		// all. Seed vm from the entry already cached for this ref, instead
+		// of a zero-valued mo.VirtualMachine, so ApplyPropertyChange merges
+		// the new fields onto the VM's existing known state rather than
+		// blanking out its name and UUID.
+		var vm mo.VirtualMachine
+		if hadOld {
+			vm.Self = old.vmRef
+			vm.Summary.Config.Name = old.name
+			vm.Summary.Config.Uuid = old.uuid
+			if old.dcRef != (types.ManagedObjectReference{}) {
+				vm.Parent = &old.dcRef
+			}
+		}
+		if err := mo.ApplyPropertyChange(&vm, update.ChangeSet); err != nil {
+			continue
+		}
+
+		entry := vmEntry{vmRef: update.Obj, name: vm.Summary.Config.Name, uuid: vm.Summary.Config.Uuid}
+		if vm.Parent != nil {
+			entry.dcRef = *vm.Parent
+		}
+
+		if hadOld {
+			if old.name != entry.name {
+				delete(m.byName, old.name)
+			}
+			if old.uuid != entry.uuid {
+				delete(m.byUUID, old.uuid)
+			}
+		}
+
+		m.byRef[update.Obj] = entry
+		if entry.name != "" {
+			m.byName[entry.name] = entry
+		}
+		if entry.uuid != "" {
+			m.byUUID[entry.uuid] = entry
+		}
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package vsphereevents turns a single vCenter PropertyCollector filter over
+// VirtualMachine, HostSystem, Datastore and Network into a stream of
+// controller-runtime events, so reconcilers can react to a changed power
+// state, network binding, VLAN or IP address in near-real-time instead of
+// polling on a fixed interval.
+package vsphereevents
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// watchedTypes are the managed object types the shared filter subscribes to.
+var watchedTypes = []string{"VirtualMachine", "HostSystem", "Datastore", "Network"}
+
+// Source opens one PropertyCollector filter over the inventory of the above
+// types and republishes incremental updates as event.GenericEvent on its
+// Channel, for use with source.Channel in a reconciler's Watches call.
+type Source struct {
+	client *vim25.Client
+	log    logr.Logger
+	resync time.Duration
+
+	channel chan event.GenericEvent
+}
+
+// NewSource returns a Source backed by client. resync bounds how long the
+// underlying filter is held open before it is torn down and re-created, as a
+// fallback against missed updates; pass 0 to rely solely on re-subscription
+// after errors.
+func NewSource(client *vim25.Client, log logr.Logger, resync time.Duration) *Source {
+	return &Source{
+		client:  client,
+		log:     log,
+		resync:  resync,
+		channel: make(chan event.GenericEvent),
+	}
+}
+
+// Channel returns the event stream to pass to source.Channel{Source: ...}.
+func (s *Source) Channel() <-chan event.GenericEvent {
+	return s.channel
+}
+
+// Start runs the watch loop in a background goroutine until ctx is
+// cancelled, re-subscribing with a capped exponential backoff whenever the
+// filter ends early, for example because the underlying session was dropped.
+func (s *Source) Start(ctx context.Context) {
+	go s.run(ctx)
+}
+
+func (s *Source) run(ctx context.Context) {
+	backoff := time.Second
+	const maxBackoff = time.Minute
+
+	for ctx.Err() == nil {
+		if err := s.watch(ctx); err != nil && ctx.Err() == nil {
+			s.log.Error(err, "vSphere property watch ended, re-subscribing", "backoff", backoff)
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return
+			}
+			if backoff < maxBackoff {
+				backoff *= 2
+			}
+			continue
+		}
+		backoff = time.Second
+	}
+}
+
+// watch opens a single filter and blocks, translating updates into
+// GenericEvents, until it errors, ctx is cancelled, or the resync interval
+// elapses (in which case it returns nil so run re-opens a fresh filter).
+func (s *Source) watch(ctx context.Context) error {
+	mgr := view.NewManager(s.client)
+	v, err := mgr.CreateContainerView(ctx, s.client.ServiceContent.RootFolder, watchedTypes, true)
+	if err != nil {
+		return err
+	}
+	defer v.Destroy(ctx)
+
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if s.resync > 0 {
+		timer := time.AfterFunc(s.resync, cancel)
+		defer timer.Stop()
+	}
+
+	pc := property.DefaultCollector(s.client)
+	filter := types.PropertyFilterSpec{
+		ObjectSet: []types.ObjectSpec{{
+			Obj:  v.Reference(),
+			Skip: types.NewBool(true),
+			SelectSet: []types.BaseSelectionSpec{&types.TraversalSpec{
+				Path: "view",
+				Skip: types.NewBool(false),
+				Type: "ContainerView",
+			}},
+		}},
+		PropSet: []types.PropertySpec{
+			{Type: "VirtualMachine", PathSet: []string{"summary.config", "summary.runtime", "summary.guest", "network"}},
+			{Type: "HostSystem", PathSet: []string{"summary"}},
+			{Type: "Datastore", PathSet: []string{"summary"}},
+			{Type: "Network", PathSet: []string{"summary"}},
+			{Type: "DistributedVirtualPortgroup", PathSet: []string{"config.defaultPortConfig"}},
+		},
+	}
+
+	err = property.WaitForUpdatesEx(watchCtx, pc, []types.PropertyFilterSpec{filter}, func(updates []types.ObjectUpdate) bool {
+		for _, update := range updates {
+			s.publish(update)
+		}
+		return false
+	})
+
+	if watchCtx.Err() != nil && ctx.Err() == nil {
+		// The resync timer fired rather than a real error; let run loop back
+		// around immediately without backing off.
+		return nil
+	}
+	return err
+}
+
+// publish emits a GenericEvent carrying the changed object's MOR as a
+// corev1.Node placeholder (Type in Namespace, Value in Name), which
+// reconcilers decode back into a types.ManagedObjectReference and map to
+// their own CRs via handler.EnqueueRequestsFromMapFunc, instead of
+// reconciling every CR on every update.
+func (s *Source) publish(update types.ObjectUpdate) {
+	s.channel <- event.GenericEvent{
+		Object: &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      update.Obj.Value,
+				Namespace: update.Obj.Type,
+			},
+		},
+	}
+}
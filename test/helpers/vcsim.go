@@ -0,0 +1,188 @@
+/*
+Copyright 2021.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package helpers boots an in-process vcsim simulator so the reconcilers can
+// be exercised against a real *vim25.Client without a live vCenter.
+package helpers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vmware/govmomi"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/simulator"
+	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/types"
+)
+
+// VCSim wraps a running simulator.Model together with the govmomi clients
+// connected to it, and the seeded inventory handles tests commonly need.
+type VCSim struct {
+	Model  *simulator.Model
+	Server *simulator.Server
+	Client *vim25.Client
+
+	Datacenter *object.Datacenter
+	Cluster    *object.ClusterComputeResource
+	Hosts      []*object.HostSystem
+	Datastore  *object.Datastore
+	Network    *object.DistributedVirtualPortgroup
+	VMs        []*object.VirtualMachine
+}
+
+// NewVCSim starts a vcsim server seeded with a small, fixed inventory: one
+// datacenter, one cluster with two hosts, a datastore, a DVS with a single
+// VLAN-tagged DVPG, and two VMs attached to that DVPG.
+func NewVCSim(ctx context.Context) (*VCSim, error) {
+	model := simulator.VPX()
+	model.Cluster = 1
+	model.ClusterHost = 2
+	model.Host = 0
+	model.Datastore = 1
+
+	if err := model.Create(); err != nil {
+		return nil, fmt.Errorf("unable to create vcsim model: %w", err)
+	}
+	model.Service.TLS = nil
+	server := model.Service.NewServer()
+
+	client, err := govmomi.NewClient(ctx, server.URL, true)
+	if err != nil {
+		model.Remove()
+		server.Close()
+		return nil, fmt.Errorf("unable to connect to vcsim: %w", err)
+	}
+
+	sim := &VCSim{
+		Model:  model,
+		Server: server,
+		Client: client.Client,
+	}
+
+	if err := sim.seedNetwork(ctx); err != nil {
+		sim.Close()
+		return nil, err
+	}
+
+	return sim, nil
+}
+
+// seedNetwork adds a DVS with a single VLAN-tagged DVPG and re-homes the
+// simulator's default VMs onto it, so reconciler tests have a distributed
+// portgroup with a known VLAN ID to assert against.
+func (s *VCSim) seedNetwork(ctx context.Context) error {
+	finder := simulator.Map.Any("Datacenter").(*simulator.Datacenter)
+	dc := object.NewDatacenter(s.Client, finder.Reference())
+	s.Datacenter = dc
+
+	folders, err := dc.Folders(ctx)
+	if err != nil {
+		return err
+	}
+
+	dvsCreateSpec := types.DVSCreateSpec{
+		ConfigSpec: &types.VMwareDVSConfigSpec{
+			DVSConfigSpec: types.DVSConfigSpec{
+				Name: "vkubeviewer-dvs",
+			},
+		},
+	}
+
+	task, err := folders.NetworkFolder.CreateDVS(ctx, dvsCreateSpec)
+	if err != nil {
+		return err
+	}
+	dvsInfo, err := task.WaitForResult(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	dvsObj := object.NewDistributedVirtualSwitch(s.Client, dvsInfo.Result.(types.ManagedObjectReference))
+
+	pgSpec := types.DVPortgroupConfigSpec{
+		Name: "vkubeviewer-dvpg",
+		DefaultPortConfig: &types.VMwareDVSPortSetting{
+			Vlan: &types.VmwareDistributedVirtualSwitchVlanIdSpec{
+				VlanId: 100,
+			},
+		},
+	}
+
+	pgTask, err := dvsObj.AddPortgroup(ctx, []types.DVPortgroupConfigSpec{pgSpec})
+	if err != nil {
+		return err
+	}
+	if _, err := pgTask.WaitForResult(ctx, nil); err != nil {
+		return err
+	}
+
+	// The simulator also holds its default DVPG and an uplink portgroup per
+	// DVS, so pick the one just added by name.
+	var pgRef types.ManagedObjectReference
+	for _, obj := range simulator.Map.All("DistributedVirtualPortgroup") {
+		if pg := obj.(*simulator.DistributedVirtualPortgroup); pg.Name == pgSpec.Name {
+			pgRef = pg.Reference()
+		}
+	}
+	s.Network = object.NewDistributedVirtualPortgroup(s.Client, pgRef)
+
+	for _, ref := range simulator.Map.All("VirtualMachine") {
+		vm := object.NewVirtualMachine(s.Client, ref.Reference())
+		if err := attachToPortgroup(ctx, vm, s.Network); err != nil {
+			return err
+		}
+		s.VMs = append(s.VMs, vm)
+	}
+
+	return nil
+}
+
+// attachToPortgroup reconfigures vm's first ethernet card to back onto pg,
+// so vm.Network reports pg instead of the simulator's default standard "VM
+// Network" — without this the VMs never actually sit on the DVPG tests
+// assert a VLAN and switch type against.
+func attachToPortgroup(ctx context.Context, vm *object.VirtualMachine, pg *object.DistributedVirtualPortgroup) error {
+	devices, err := vm.Device(ctx)
+	if err != nil {
+		return err
+	}
+
+	nics := devices.SelectByType((*types.VirtualEthernetCard)(nil))
+	if len(nics) == 0 {
+		return fmt.Errorf("vm %s has no ethernet card to attach to %s", vm.Reference(), pg.Reference())
+	}
+
+	backing, err := pg.EthernetCardBackingInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	card := nics[0].(types.BaseVirtualEthernetCard).GetVirtualEthernetCard()
+	card.Backing = backing
+
+	return vm.EditDevice(ctx, nics[0])
+}
+
+// Close tears down the simulator server and its in-memory model.
+func (s *VCSim) Close() {
+	if s.Server != nil {
+		s.Server.Close()
+	}
+	if s.Model != nil {
+		s.Model.Remove()
+	}
+}